@@ -0,0 +1,63 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DaemonsetState reports the readiness of a single required daemonset's pod on the node
+type DaemonsetState struct {
+	// Name of the daemonset
+	Name string `json:"name"`
+	// Namespace of the daemonset
+	Namespace string `json:"namespace"`
+	// PodName is the name of the pod backing this daemonset on the node, empty if none was found
+	PodName string `json:"podName,omitempty"`
+	// Ready is whether the pod is currently passing its readiness checks
+	Ready bool `json:"ready"`
+	// LastTransitionTime is the last time Ready changed value
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// NidhoggNodeStateSpec defines the desired state of NidhoggNodeState
+type NidhoggNodeStateSpec struct {
+	// NodeName is the node this state describes
+	NodeName string `json:"nodeName"`
+}
+
+// NidhoggNodeStateStatus defines the observed state of NidhoggNodeState
+type NidhoggNodeStateStatus struct {
+	// Daemonsets reports the readiness of every daemonset nidhogg requires on this node
+	Daemonsets []DaemonsetState `json:"daemonsets,omitempty"`
+	// Taints are the nidhogg.uswitch.com/* taints currently applied to the node
+	Taints []corev1.Taint `json:"taints,omitempty"`
+}
+
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NidhoggNodeState is a cluster-scoped resource, one per node matched by
+// nidhogg, that publishes the readiness of each required daemonset and the
+// taints nidhogg currently has applied. It lets other controllers react to a
+// node becoming fully ready without re-implementing nidhogg's own logic.
+type NidhoggNodeState struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NidhoggNodeStateSpec   `json:"spec,omitempty"`
+	Status NidhoggNodeStateStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// NidhoggNodeStateList contains a list of NidhoggNodeState
+type NidhoggNodeStateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NidhoggNodeState `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NidhoggNodeState{}, &NidhoggNodeStateList{})
+}
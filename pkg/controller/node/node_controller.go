@@ -18,13 +18,15 @@ package node
 import (
 	"context"
 
+	"github.com/uswitch/nidhogg/pkg/apis"
 	"github.com/uswitch/nidhogg/pkg/nidhogg"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
-	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
@@ -32,15 +34,78 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
+// podNodeNameIndex is the field index registered on the manager's cache so pods
+// can be looked up by node without listing and scanning an entire namespace.
+const podNodeNameIndex = "spec.nodeName"
+
 // Add creates a new Node Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
 func Add(mgr manager.Manager, cfg nidhogg.HandlerConfig) error {
-	return add(mgr, newReconciler(mgr, cfg))
+	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+		return err
+	}
+	if err := addPodNodeNameIndex(mgr.GetCache()); err != nil {
+		return err
+	}
+	return add(mgr, cfg, newReconciler(mgr, cfg))
+}
+
+// addPodNodeNameIndex registers a field indexer on spec.nodeName, which backs
+// the handler's PodGetter with an indexed cache lookup instead of a full List.
+func addPodNodeNameIndex(informerCache cache.Cache) error {
+	return informerCache.IndexField(&corev1.Pod{}, podNodeNameIndex, func(obj runtime.Object) []string {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || pod.Spec.NodeName == "" {
+			return nil
+		}
+		return []string{pod.Spec.NodeName}
+	})
+}
+
+// newPodGetter returns a nidhogg.PodGetter backed by the manager's indexed cache
+func newPodGetter(informerCache cache.Cache) nidhogg.PodGetter {
+	return func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+		pods := &corev1.PodList{}
+		opts := client.InNamespace(namespace).MatchingField(podNodeNameIndex, nodeName)
+		if err := informerCache.List(context.TODO(), opts, pods); err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			if match(&pods.Items[i]) {
+				return &pods.Items[i], nil
+			}
+		}
+		return nil, nil
+	}
+}
+
+// newNodeGetter returns a nidhogg.NodeGetter backed by the manager's client
+func newNodeGetter(c client.Client) nidhogg.NodeGetter {
+	return func(name string) (*corev1.Node, error) {
+		node := &corev1.Node{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Name: name}, node); err != nil {
+			return nil, err
+		}
+		return node, nil
+	}
+}
+
+// newDaemonsetGetter returns a nidhogg.DaemonsetGetter backed by the manager's
+// client, used by the disruption subsystem to detect daemonset spec drift
+func newDaemonsetGetter(c client.Client) nidhogg.DaemonsetGetter {
+	return func(namespace, name string) (*appsv1.DaemonSet, error) {
+		ds := &appsv1.DaemonSet{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, ds); err != nil {
+			return nil, err
+		}
+		return ds, nil
+	}
 }
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager, cfg nidhogg.HandlerConfig) reconcile.Reconciler {
-	return &ReconcileNode{handler: nidhogg.NewHandler(mgr.GetClient(), mgr.GetRecorder("nidhogg"), cfg), scheme: mgr.GetScheme()}
+	handler := nidhogg.NewHandler(mgr.GetClient(), mgr.GetRecorder("nidhogg"), cfg, newPodGetter(mgr.GetCache()), newNodeGetter(mgr.GetClient()), newDaemonsetGetter(mgr.GetClient()))
+	return &ReconcileNode{handler: handler, scheme: mgr.GetScheme()}
 }
 
 type nodeEnqueue struct{}
@@ -64,21 +129,27 @@ func (e *nodeEnqueue) Create(evt event.CreateEvent, q workqueue.RateLimitingInte
 	}})
 }
 
-type podEnqueue struct{}
+// podEnqueue maps daemonset pod events to their node, so HandleNode gets a
+// chance to re-evaluate taints whenever a watched daemonset pod changes
+type podEnqueue struct {
+	cfg nidhogg.HandlerConfig
+}
+
+// newPodEnqueue builds a podEnqueue from the configured daemonsets
+func newPodEnqueue(cfg nidhogg.HandlerConfig) *podEnqueue {
+	return &podEnqueue{cfg: cfg}
+}
 
 // Generic implements the interface
 func (e *podEnqueue) Generic(_ event.GenericEvent, _ workqueue.RateLimitingInterface) {}
 
-// canAddToQueue check if the Pod is associated to a node and is a daemonset pod
+// canAddToQueue check if the Pod is associated to a node and is the required
+// pod of one of the daemonsets nidhogg is configured to watch
 func (e *podEnqueue) canAddToQueue(pod *corev1.Pod) bool {
 	if pod.Spec.NodeName == "" {
 		return false
 	}
-	owner := v1.GetControllerOf(pod)
-	if owner == nil {
-		return false
-	}
-	return owner.Kind == "DaemonSet"
+	return e.cfg.PodMatchesAny(pod)
 }
 
 // Create adds the node of the daemonset pod to the queue
@@ -125,7 +196,7 @@ func (e *podEnqueue) Delete(evt event.DeleteEvent, q workqueue.RateLimitingInter
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+func add(mgr manager.Manager, cfg nidhogg.HandlerConfig, r reconcile.Reconciler) error {
 	// Create a new controller
 	c, err := controller.New("node-controller", mgr, controller.Options{
 		Reconciler:              r,
@@ -141,7 +212,7 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 		return err
 	}
 
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &podEnqueue{})
+	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, newPodEnqueue(cfg))
 	if err != nil {
 		return err
 	}
@@ -163,18 +234,8 @@ type ReconcileNode struct {
 // +kubebuilder:rbac:groups=core,resources=nodes/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=,resources=events,verbs=create;update;patch
+// +kubebuilder:rbac:groups=nidhogg.uswitch.com,resources=nidhoggnodestates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=daemonsets,verbs=get;list;watch
 func (r *ReconcileNode) Reconcile(request reconcile.Request) (reconcile.Result, error) {
-	// Fetch the Node instance
-	instance := &corev1.Node{}
-	err := r.handler.Get(context.TODO(), request.NamespacedName, instance)
-	if err != nil {
-		if errors.IsNotFound(err) {
-			// Object not found, return.  Created objects are automatically garbage collected.
-			// For additional cleanup logic use finalizers.
-			return reconcile.Result{}, nil
-		}
-		// Error reading the object - requeue the request.
-		return reconcile.Result{}, err
-	}
-	return r.handler.HandleNode(instance)
+	return r.handler.HandleNode(request.Name)
 }
@@ -8,8 +8,13 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/uswitch/nidhogg/pkg/apis/nidhogg/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -23,6 +28,13 @@ const (
 	taintOperationAdded      = "added"
 	taintOperationRemoved    = "removed"
 	annotationFirstTimeReady = taintKey + "/first-time-ready"
+	// expiredTaint is applied on top of a daemonset's regular taint once the
+	// disruption subsystem has escalated a node, so the escalation is visible
+	// on the node itself rather than only in events and metrics.
+	expiredTaint = taintKey + "/expired"
+
+	escalationReasonExpired = "expired"
+	escalationReasonDrifted = "drifted"
 )
 
 var (
@@ -43,46 +55,235 @@ var (
 			"operation",
 		},
 	)
+	disruptionEscalations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "disruption_escalations",
+		Help: "Total number of nodes escalated by the disruption subsystem, by reason and daemonset",
+	},
+		[]string{
+			"reason",
+			"daemonset",
+		},
+	)
 )
 
 func init() {
 	metrics.Registry.MustRegister(
 		taintOperations,
 		taintOperationErrors,
+		disruptionEscalations,
 	)
 }
 
+// PodGetter looks up the pod matching match on a given node and namespace.
+// Implementations are expected to be backed by an indexed informer cache
+// rather than issuing a live List call, so repeated lookups across reconciles
+// stay cheap.
+type PodGetter func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error)
+
+// NodeGetter looks up a node by name, mirroring PodGetter. The handler still
+// uses its embedded client to update nodes and read and write NidhoggNodeStates.
+type NodeGetter func(name string) (*corev1.Node, error)
+
+// DaemonsetGetter looks up the live state of a daemonset, used by the
+// disruption subsystem to detect spec drift. A nil DaemonsetGetter simply
+// disables drift detection.
+type DaemonsetGetter func(namespace, name string) (*appsv1.DaemonSet, error)
+
 // Handler performs the main business logic of the Wave controller
 type Handler struct {
 	client.Client
-	recorder record.EventRecorder
-	config   HandlerConfig
+	recorder        record.EventRecorder
+	config          HandlerConfig
+	podGetter       PodGetter
+	nodeGetter      NodeGetter
+	daemonsetGetter DaemonsetGetter
 }
 
 // HandlerConfig contains the options for Nidhogg
 type HandlerConfig struct {
 	Daemonsets   []Daemonset `json:"daemonsets" yaml:"daemonsets"`
 	NodeSelector []string    `json:"nodeSelector" yaml:"nodeSelector"`
-	Selector     labels.Selector
+	// Disruption configures nidhogg's default escalation behaviour for nodes
+	// stuck under a nidhogg taint. Per-daemonset entries may override it.
+	Disruption DisruptionConfig `json:"disruption,omitempty" yaml:"disruption,omitempty"`
+	Selector   labels.Selector
+}
+
+// DisruptionConfig configures nidhogg's escalation behaviour for nodes that
+// have carried a nidhogg taint for too long, borrowing Karpenter's
+// disruption model: expiration triggered by taint age, drift triggered by
+// the blocking daemonset's spec changing out from under a stuck node.
+type DisruptionConfig struct {
+	// MaxTaintAge is how long a nidhogg taint may remain on a node before
+	// nidhogg considers it expired and escalates. Zero disables
+	// expiration-based escalation.
+	MaxTaintAge time.Duration `json:"maxTaintAge,omitempty" yaml:"maxTaintAge,omitempty"`
+	// MinDriftAge is how long a nidhogg taint must have been on a node before
+	// a drifted daemonset is allowed to escalate it, so a routine rolling
+	// update isn't mistaken for a stuck node. Zero disables drift-based
+	// escalation.
+	MinDriftAge time.Duration `json:"minDriftAge,omitempty" yaml:"minDriftAge,omitempty"`
+	// RequeueInterval re-examines tainted nodes on this period even without a
+	// pod or node event, so expiration and drift are still caught on quiet
+	// clusters. Zero disables the periodic requeue.
+	RequeueInterval time.Duration `json:"requeueInterval,omitempty" yaml:"requeueInterval,omitempty"`
+	// Cordon marks the node unschedulable on escalation, in addition to
+	// applying the expired taint.
+	Cordon bool `json:"cordon,omitempty" yaml:"cordon,omitempty"`
 }
 
-func (hc *HandlerConfig) BuildSelectors() {
-	print("test")
+// DaemonsetDisruption overrides DisruptionConfig's escalation thresholds for
+// a single daemonset.
+type DaemonsetDisruption struct {
+	MaxTaintAge *time.Duration `json:"maxTaintAge,omitempty" yaml:"maxTaintAge,omitempty"`
+	MinDriftAge *time.Duration `json:"minDriftAge,omitempty" yaml:"minDriftAge,omitempty"`
+	Cordon      *bool          `json:"cordon,omitempty" yaml:"cordon,omitempty"`
+}
+
+// BuildSelectors resolves the node selector and per-daemonset pod selectors
+// from their raw config representations, ready for use by the handler
+func (hc *HandlerConfig) BuildSelectors() error {
 	hc.Selector = labels.Everything()
 	for _, rawSelector := range hc.NodeSelector {
-		if selector, err := labels.Parse(rawSelector); err != nil {
-			panic(err)
-		} else {
-			requirements, _ := selector.Requirements()
-			hc.Selector = hc.Selector.Add(requirements...)
+		selector, err := labels.Parse(rawSelector)
+		if err != nil {
+			return fmt.Errorf("error parsing node selector %q: %v", rawSelector, err)
 		}
+		requirements, _ := selector.Requirements()
+		hc.Selector = hc.Selector.Add(requirements...)
 	}
+	for i := range hc.Daemonsets {
+		ds := &hc.Daemonsets[i]
+		switch ds.effect() {
+		case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("invalid taint effect %q for daemonset %s/%s", ds.Effect, ds.Namespace, ds.Name)
+		}
+		selector, err := ds.buildPodSelector()
+		if err != nil {
+			return fmt.Errorf("error parsing pod selector for daemonset %s/%s: %v", ds.Namespace, ds.Name, err)
+		}
+		ds.selector = selector
+	}
+	return nil
 }
 
-// Daemonset contains the name and namespace of a Daemonset
+// PodMatchesAny reports whether pod is the required pod of any configured
+// daemonset, used by the pod watch predicate to decide whether an event is
+// worth enqueuing
+func (hc *HandlerConfig) PodMatchesAny(pod *corev1.Pod) bool {
+	for i := range hc.Daemonsets {
+		if hc.Daemonsets[i].podMatches(pod) {
+			return true
+		}
+	}
+	return false
+}
+
+// Daemonset identifies a required per-node pod that nidhogg waits on before
+// considering a node ready. By default it matches pods owned by a DaemonSet
+// called Name in Namespace; set LabelSelector to match the pod by label
+// instead, which also covers StatefulSets, static pods, or pods fronted by an
+// intermediate controller that don't carry a DaemonSet owner reference.
 type Daemonset struct {
 	Name      string `json:"name" yaml:"name"`
 	Namespace string `json:"namespace" yaml:"namespace"`
+	// LabelSelector, when set, matches the required pod by label rather than
+	// by the owner-reference shorthand below.
+	LabelSelector string `json:"labelSelector,omitempty" yaml:"labelSelector,omitempty"`
+	// OwnerKind overrides the owner kind matched by the owner-reference
+	// shorthand. Only used when LabelSelector is unset. Defaults to "DaemonSet".
+	OwnerKind string `json:"ownerKind,omitempty" yaml:"ownerKind,omitempty"`
+	// OwnerAPIGroup overrides the owner API group matched by the owner-reference
+	// shorthand. Only used when LabelSelector is unset. Defaults to "apps".
+	OwnerAPIGroup string `json:"ownerAPIGroup,omitempty" yaml:"ownerAPIGroup,omitempty"`
+	// Effect is the taint effect applied while this daemonset's pod isn't ready
+	// on a node. Defaults to NoSchedule. NoExecute additionally evicts pods
+	// already running on the node that don't tolerate it.
+	Effect corev1.TaintEffect `json:"effect,omitempty" yaml:"effect,omitempty"`
+	// Disruption overrides the handler's default DisruptionConfig for this
+	// daemonset.
+	Disruption *DaemonsetDisruption `json:"disruption,omitempty" yaml:"disruption,omitempty"`
+
+	// selector is LabelSelector parsed at BuildSelectors time, nil when
+	// LabelSelector is unset and the owner-reference shorthand applies instead
+	selector labels.Selector
+}
+
+// effect returns the configured taint effect, defaulting to NoSchedule
+func (ds Daemonset) effect() corev1.TaintEffect {
+	if ds.Effect == "" {
+		return corev1.TaintEffectNoSchedule
+	}
+	return ds.Effect
+}
+
+// buildPodSelector parses LabelSelector, returning a nil selector when unset
+// so podMatches knows to fall back to the owner-reference shorthand
+func (ds *Daemonset) buildPodSelector() (labels.Selector, error) {
+	if ds.LabelSelector == "" {
+		return nil, nil
+	}
+	return labels.Parse(ds.LabelSelector)
+}
+
+func (ds *Daemonset) ownerKind() string {
+	if ds.OwnerKind == "" {
+		return "DaemonSet"
+	}
+	return ds.OwnerKind
+}
+
+func (ds *Daemonset) ownerAPIGroup() string {
+	if ds.OwnerAPIGroup == "" {
+		return "apps"
+	}
+	return ds.OwnerAPIGroup
+}
+
+// maxTaintAge returns the effective MaxTaintAge for this daemonset, falling
+// back to the handler-wide default when it hasn't overridden it
+func (ds *Daemonset) maxTaintAge(defaults DisruptionConfig) time.Duration {
+	if ds.Disruption != nil && ds.Disruption.MaxTaintAge != nil {
+		return *ds.Disruption.MaxTaintAge
+	}
+	return defaults.MaxTaintAge
+}
+
+// minDriftAge returns the effective MinDriftAge for this daemonset, falling
+// back to the handler-wide default when it hasn't overridden it
+func (ds *Daemonset) minDriftAge(defaults DisruptionConfig) time.Duration {
+	if ds.Disruption != nil && ds.Disruption.MinDriftAge != nil {
+		return *ds.Disruption.MinDriftAge
+	}
+	return defaults.MinDriftAge
+}
+
+// cordonOnEscalation returns whether escalating this daemonset should also
+// cordon the node, falling back to the handler-wide default
+func (ds *Daemonset) cordonOnEscalation(defaults DisruptionConfig) bool {
+	if ds.Disruption != nil && ds.Disruption.Cordon != nil {
+		return *ds.Disruption.Cordon
+	}
+	return defaults.Cordon
+}
+
+// podMatches reports whether pod is the required pod for this daemonset,
+// either by label selector or by owner reference depending on how the entry
+// is configured
+func (ds *Daemonset) podMatches(pod *corev1.Pod) bool {
+	if ds.selector != nil {
+		return ds.selector.Matches(labels.Set(pod.Labels))
+	}
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return false
+	}
+	return owner.Kind == ds.ownerKind() && gv.Group == ds.ownerAPIGroup() && owner.Name == ds.Name
 }
 
 type taintChanges struct {
@@ -90,27 +291,45 @@ type taintChanges struct {
 	taintsRemoved []string
 }
 
-// NewHandler constructs a new instance of Handler
-func NewHandler(c client.Client, r record.EventRecorder, conf HandlerConfig) *Handler {
-	return &Handler{Client: c, recorder: r, config: conf}
+// NewHandler constructs a new instance of Handler. daemonsetGetter may be nil,
+// which disables drift detection in the disruption subsystem.
+func NewHandler(c client.Client, r record.EventRecorder, conf HandlerConfig, podGetter PodGetter, nodeGetter NodeGetter, daemonsetGetter DaemonsetGetter) *Handler {
+	return &Handler{Client: c, recorder: r, config: conf, podGetter: podGetter, nodeGetter: nodeGetter, daemonsetGetter: daemonsetGetter}
 }
 
 // HandleNode works out what taints need to be applied to the node
-func (h *Handler) HandleNode(instance *corev1.Node) (reconcile.Result, error) {
+func (h *Handler) HandleNode(nodeName string) (reconcile.Result, error) {
 
 	log := logf.Log.WithName("nidhogg")
 
+	instance, err := h.nodeGetter(nodeName)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Node is gone, clean up the NidhoggNodeState that tracked it
+			if err := h.deleteNodeState(nodeName); err != nil && !errors.IsNotFound(err) {
+				return reconcile.Result{}, fmt.Errorf("error deleting node state: %v", err)
+			}
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, fmt.Errorf("error fetching node: %v", err)
+	}
+
 	//check whether node matches the nodeSelector
 	if !h.config.Selector.Matches(labels.Set(instance.Labels)) {
 		return reconcile.Result{}, nil
 	}
 
-	nodeCopy, taintChanges, err := h.calculateTaints(instance)
+	nodeCopy, taintChanges, daemonsetStates, escalation, err := h.calculateTaints(instance)
 	if err != nil {
 		taintOperationErrors.WithLabelValues("calculateTaints").Inc()
 		return reconcile.Result{}, fmt.Errorf("error caluclating taints for node: %v", err)
 	}
 
+	if err := h.syncNodeState(instance.Name, daemonsetStates, nidhoggTaints(nodeCopy.Spec.Taints)); err != nil {
+		taintOperationErrors.WithLabelValues("nodeStateSync").Inc()
+		return reconcile.Result{}, fmt.Errorf("error syncing node state: %v", err)
+	}
+
 	taintLess := true
 	for _, taint := range nodeCopy.Spec.Taints {
 		if strings.HasPrefix(taint.Key, taintKey) {
@@ -118,6 +337,11 @@ func (h *Handler) HandleNode(instance *corev1.Node) (reconcile.Result, error) {
 		}
 	}
 
+	var requeueAfter time.Duration
+	if !taintLess && h.config.Disruption.RequeueInterval > 0 {
+		requeueAfter = h.config.Disruption.RequeueInterval
+	}
+
 	var firstTimeReady string
 	if taintLess {
 		firstTimeReady = time.Now().Format("2006-01-02T15:04:05Z")
@@ -153,75 +377,238 @@ func (h *Handler) HandleNode(instance *corev1.Node) (reconcile.Result, error) {
 		nodeCopy.UID = types.UID(nodeCopy.Name)
 
 		h.recorder.Eventf(nodeCopy, corev1.EventTypeNormal, "TaintsChanged", "Taints added: %s, Taints removed: %s, TaintLess: %v, FirstTimeReady: %q", taintChanges.taintsAdded, taintChanges.taintsRemoved, taintLess, firstTimeReady)
+
+		// only report the escalation once it's actually persisted, so a
+		// failed Update (routine on spec conflicts with other taint-writers)
+		// doesn't double-count the metric/event on the next reconcile's retry
+		if escalation != nil {
+			disruptionEscalations.WithLabelValues(escalation.reason, escalation.daemonsetName).Inc()
+			h.recorder.Eventf(nodeCopy, corev1.EventTypeWarning, "NidhoggDisruption", "Node %s escalated (%s) waiting on daemonset %s", nodeCopy.Name, escalation.reason, escalation.daemonsetName)
+		}
 	}
 
-	return reconcile.Result{}, nil
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
 }
 
-func (h *Handler) calculateTaints(instance *corev1.Node) (*corev1.Node, taintChanges, error) {
+func (h *Handler) calculateTaints(instance *corev1.Node) (*corev1.Node, taintChanges, []v1alpha1.DaemonsetState, *escalationEvent, error) {
 
 	nodeCopy := instance.DeepCopy()
 
 	var changes taintChanges
+	var states []v1alpha1.DaemonsetState
 
-	taintsToRemove := make(map[string]struct{})
+	// taints are tracked by key alone; the effect is not part of their
+	// identity. An existing taint whose effect differs from the configured one
+	// has every taint with that key removed and is re-added with the new
+	// effect below, rather than being kept alongside the new one.
+	taintsToRemove := make(map[string]corev1.Taint)
 	for _, taint := range nodeCopy.Spec.Taints {
 		// we could have some older taints from a different configuration file
 		// storing them all to reconcile from a previous state
 		if strings.HasPrefix(taint.Key, taintKey) {
-			taintsToRemove[taint.Key] = struct{}{}
+			taintsToRemove[taint.Key] = taint
 		}
 	}
+	// escalationReason/escalatedDaemonset record the first daemonset found to
+	// warrant disruption escalation this round, applied once after the main
+	// loop so the synthetic expiredTaint is handled the same way as the
+	// per-daemonset taints below (kept, added or swept by the final loop)
+	var escalationReason string
+	var escalatedDaemonset Daemonset
 	for _, daemonset := range h.config.Daemonsets {
 
 		taint := fmt.Sprintf("%s/%s.%s", taintKey, daemonset.Namespace, daemonset.Name)
+		effect := daemonset.effect()
 		// Get Pod for node
 		pod, err := h.getDaemonsetPod(instance.Name, daemonset)
 		if err != nil {
-			return nil, taintChanges{}, fmt.Errorf("error fetching pods: %v", err)
+			return nil, taintChanges{}, nil, nil, fmt.Errorf("error fetching pods: %v", err)
+		}
+
+		state := v1alpha1.DaemonsetState{
+			Name:      daemonset.Name,
+			Namespace: daemonset.Namespace,
+			Ready:     pod != nil && podReady(pod),
+		}
+		if pod != nil {
+			state.PodName = pod.Name
 		}
+		states = append(states, state)
 
 		if pod != nil && podReady(pod) {
 			// if the taint is in the taintsToRemove map, it'll be removed
 			continue
 		}
 		// pod doesn't exist or is not ready
-		_, ok := taintsToRemove[taint]
+		existing, ok := taintsToRemove[taint]
 		if ok {
 			// we want to keep this already existing taint on it
 			delete(taintsToRemove, taint)
-			continue
+			if existing.Effect == effect {
+				if escalationReason == "" {
+					escalationReason = h.daemonsetEscalationReason(daemonset, existing)
+					escalatedDaemonset = daemonset
+				}
+				continue
+			}
+			// the configured effect has changed since the taint was applied,
+			// replace it rather than leaving the stale effect in place
+			nodeCopy.Spec.Taints = removeTaint(nodeCopy.Spec.Taints, taint)
+			changes.taintsRemoved = append(changes.taintsRemoved, taint)
 		}
-		// taint is not already present, adding it
+		// taint is not already present (or is present with a stale effect), add it
 		changes.taintsAdded = append(changes.taintsAdded, taint)
-		nodeCopy.Spec.Taints = addTaint(nodeCopy.Spec.Taints, taint)
+		nodeCopy.Spec.Taints = addTaint(nodeCopy.Spec.Taints, taint, effect)
+	}
+	var escalation *escalationEvent
+	if escalationReason != "" {
+		escalation = h.escalate(nodeCopy, escalatedDaemonset, escalationReason, taintsToRemove, &changes)
 	}
 	for taint := range taintsToRemove {
+		if taint == expiredTaint {
+			// the node is no longer stuck, so any cordon nidhogg applied on
+			// escalation no longer applies either
+			nodeCopy.Spec.Unschedulable = false
+		}
 		nodeCopy.Spec.Taints = removeTaint(nodeCopy.Spec.Taints, taint)
 		changes.taintsRemoved = append(changes.taintsRemoved, taint)
 	}
-	return nodeCopy, changes, nil
+	return nodeCopy, changes, states, escalation, nil
 }
 
 func (h *Handler) getDaemonsetPod(nodeName string, ds Daemonset) (*corev1.Pod, error) {
-	opts := client.InNamespace(ds.Namespace)
-	pods := &corev1.PodList{}
-	err := h.List(context.TODO(), opts, pods)
+	return h.podGetter(nodeName, ds.Namespace, ds.podMatches)
+}
+
+// daemonsetEscalationReason reports why daemonset's existing taint warrants
+// disruption escalation, or "" if it doesn't: either the taint is older than
+// the configured MaxTaintAge, or the daemonset has drifted from its desired
+// spec and the taint has stuck around for at least MinDriftAge, mirroring
+// Karpenter's expiration/drift disruption model. Both checks are age-gated so
+// a routine rolling update, which drifts the daemonset for the duration of
+// the rollout, isn't mistaken for a stuck node on the very next reconcile.
+func (h *Handler) daemonsetEscalationReason(daemonset Daemonset, taint corev1.Taint) string {
+	if taint.TimeAdded == nil {
+		return ""
+	}
+	age := time.Since(taint.TimeAdded.Time)
+	if maxAge := daemonset.maxTaintAge(h.config.Disruption); maxAge > 0 && age > maxAge {
+		return escalationReasonExpired
+	}
+	if minDriftAge := daemonset.minDriftAge(h.config.Disruption); minDriftAge > 0 && age > minDriftAge && h.daemonsetDrifted(daemonset) {
+		return escalationReasonDrifted
+	}
+	return ""
+}
+
+// escalationEvent describes a newly-applied escalation, reported by escalate
+// and left for the caller to turn into a metric/event once the node update
+// it depends on has actually been persisted.
+type escalationEvent struct {
+	reason        string
+	daemonsetName string
+}
+
+// escalate applies the expiredTaint to nodeCopy, keeping expiredTaint out of
+// taintsToRemove so the caller's final sweep doesn't clear it straight back
+// off again. It reports nil if the node was already escalated, since repeat
+// reconciles of an already-escalated node are a no-op beyond that and
+// shouldn't be reported again.
+func (h *Handler) escalate(nodeCopy *corev1.Node, daemonset Daemonset, reason string, taintsToRemove map[string]corev1.Taint, changes *taintChanges) *escalationEvent {
+	delete(taintsToRemove, expiredTaint)
+
+	for _, t := range nodeCopy.Spec.Taints {
+		if t.Key == expiredTaint {
+			return nil
+		}
+	}
+
+	if daemonset.cordonOnEscalation(h.config.Disruption) {
+		nodeCopy.Spec.Unschedulable = true
+	}
+
+	changes.taintsAdded = append(changes.taintsAdded, expiredTaint)
+	nodeCopy.Spec.Taints = addTaint(nodeCopy.Spec.Taints, expiredTaint, corev1.TaintEffectNoExecute)
+
+	return &escalationEvent{reason: reason, daemonsetName: daemonset.Namespace + "/" + daemonset.Name}
+}
+
+// daemonsetDrifted reports whether daemonset's live spec has moved on from
+// what's currently rolled out, approximated by the daemonset controller not
+// having converged yet, since client-go has no cheap way to compare a pod's
+// template hash against the daemonset's desired template directly
+func (h *Handler) daemonsetDrifted(daemonset Daemonset) bool {
+	if h.daemonsetGetter == nil {
+		return false
+	}
+	current, err := h.daemonsetGetter(daemonset.Namespace, daemonset.Name)
 	if err != nil {
-		return nil, err
+		return false
 	}
+	return current.Generation != current.Status.ObservedGeneration ||
+		current.Status.UpdatedNumberScheduled < current.Status.DesiredNumberScheduled
+}
 
-	for _, pod := range pods.Items {
-		for _, owner := range pod.OwnerReferences {
-			if owner.Name == ds.Name {
-				if pod.Spec.NodeName == nodeName {
-					return &pod, nil
-				}
+// syncNodeState creates or updates the NidhoggNodeState for nodeName to reflect
+// the daemonset readiness and taints just computed for it
+func (h *Handler) syncNodeState(nodeName string, states []v1alpha1.DaemonsetState, taints []corev1.Taint) error {
+	existing := &v1alpha1.NidhoggNodeState{}
+	err := h.Get(context.TODO(), types.NamespacedName{Name: nodeName}, existing)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	notFound := errors.IsNotFound(err)
+
+	if !notFound {
+		previous := make(map[string]v1alpha1.DaemonsetState, len(existing.Status.Daemonsets))
+		for _, ds := range existing.Status.Daemonsets {
+			previous[ds.Namespace+"/"+ds.Name] = ds
+		}
+		for i, ds := range states {
+			if prev, ok := previous[ds.Namespace+"/"+ds.Name]; ok && prev.Ready == ds.Ready {
+				states[i].LastTransitionTime = prev.LastTransitionTime
+			} else {
+				states[i].LastTransitionTime = metav1.Now()
 			}
 		}
+	} else {
+		now := metav1.Now()
+		for i := range states {
+			states[i].LastTransitionTime = now
+		}
+	}
+
+	status := v1alpha1.NidhoggNodeStateStatus{Daemonsets: states, Taints: taints}
+
+	if notFound {
+		desired := &v1alpha1.NidhoggNodeState{
+			ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+			Spec:       v1alpha1.NidhoggNodeStateSpec{NodeName: nodeName},
+			Status:     status,
+		}
+		// with the status subresource enabled the API server drops Status on
+		// Create as well, so write it through the status writer afterwards
+		if err := h.Create(context.TODO(), desired); err != nil {
+			return err
+		}
+		desired.Status = status
+		return h.Status().Update(context.TODO(), desired)
 	}
 
-	return nil, nil
+	if reflect.DeepEqual(existing.Status, status) {
+		return nil
+	}
+
+	desired := existing.DeepCopy()
+	desired.Status = status
+	// the CRD has the status subresource enabled, so a plain Update here
+	// would silently drop these changes; go through the status writer instead
+	return h.Status().Update(context.TODO(), desired)
+}
+
+// deleteNodeState removes the NidhoggNodeState for a node that no longer exists
+func (h *Handler) deleteNodeState(nodeName string) error {
+	return h.Delete(context.TODO(), &v1alpha1.NidhoggNodeState{ObjectMeta: metav1.ObjectMeta{Name: nodeName}})
 }
 
 func podReady(pod *corev1.Pod) bool {
@@ -233,8 +620,12 @@ func podReady(pod *corev1.Pod) bool {
 	return true
 }
 
-func addTaint(taints []corev1.Taint, taintName string) []corev1.Taint {
-	return append(taints, corev1.Taint{Key: taintName, Effect: corev1.TaintEffectNoSchedule})
+// addTaint appends a new taint, stamping TimeAdded so the disruption
+// subsystem can later measure how long it's been in place. Built-in
+// NoExecute eviction also relies on TimeAdded being set.
+func addTaint(taints []corev1.Taint, taintName string, effect corev1.TaintEffect) []corev1.Taint {
+	now := metav1.Now()
+	return append(taints, corev1.Taint{Key: taintName, Effect: effect, TimeAdded: &now})
 }
 
 func removeTaint(taints []corev1.Taint, taintName string) []corev1.Taint {
@@ -248,3 +639,16 @@ func removeTaint(taints []corev1.Taint, taintName string) []corev1.Taint {
 	}
 	return newTaints
 }
+
+// nidhoggTaints filters taints down to the ones nidhogg itself manages, so
+// callers publishing them (e.g. NidhoggNodeState) don't leak unrelated taints
+// applied by other controllers (cloud-provider, autoscaler, node lifecycle, ...)
+func nidhoggTaints(taints []corev1.Taint) []corev1.Taint {
+	var nidhogg []corev1.Taint
+	for _, taint := range taints {
+		if strings.HasPrefix(taint.Key, taintKey) {
+			nidhogg = append(nidhogg, taint)
+		}
+	}
+	return nidhogg
+}
@@ -0,0 +1,328 @@
+package nidhogg
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func podWithReadiness(ready bool) *corev1.Pod {
+	return &corev1.Pod{
+		Status: corev1.PodStatus{
+			ContainerStatuses: []corev1.ContainerStatus{
+				{Ready: ready},
+			},
+		},
+	}
+}
+
+func TestCalculateTaintsEffectTransition(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Taints = []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoSchedule}}
+
+	h := &Handler{
+		config: HandlerConfig{Daemonsets: []Daemonset{
+			{Name: "foo", Namespace: "kube-system", Effect: corev1.TaintEffectNoExecute},
+		}},
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(false), nil
+		},
+	}
+
+	nodeCopy, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(nodeCopy.Spec.Taints) != 1 {
+		t.Fatalf("expected exactly one taint, got %d: %+v", len(nodeCopy.Spec.Taints), nodeCopy.Spec.Taints)
+	}
+	if nodeCopy.Spec.Taints[0].Effect != corev1.TaintEffectNoExecute {
+		t.Fatalf("expected taint effect to become NoExecute, got %s", nodeCopy.Spec.Taints[0].Effect)
+	}
+	if nodeCopy.Spec.Taints[0].TimeAdded == nil {
+		t.Fatalf("expected TimeAdded to be set when applying a NoExecute taint")
+	}
+
+	if !contains(changes.taintsAdded, taintName) || !contains(changes.taintsRemoved, taintName) {
+		t.Fatalf("expected taint to be recorded as both removed (old effect) and added (new effect), got %+v", changes)
+	}
+}
+
+func TestCalculateTaintsPodReadyMidEviction(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Taints = []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoExecute}}
+
+	h := &Handler{
+		config: HandlerConfig{Daemonsets: []Daemonset{
+			{Name: "foo", Namespace: "kube-system", Effect: corev1.TaintEffectNoExecute},
+		}},
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(true), nil
+		},
+	}
+
+	nodeCopy, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodeCopy.Spec.Taints) != 0 {
+		t.Fatalf("expected taint to be removed once the pod becomes ready, got %+v", nodeCopy.Spec.Taints)
+	}
+	if !reflect.DeepEqual(changes.taintsRemoved, []string{taintName}) {
+		t.Fatalf("expected taint removal to be recorded, got %+v", changes)
+	}
+}
+
+func TestDaemonsetPodMatchesOwnerShorthand(t *testing.T) {
+	ds := Daemonset{Name: "foo", Namespace: "kube-system"}
+
+	owned := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "DaemonSet", Name: "foo", Controller: boolPtr(true)},
+			},
+		},
+	}
+	if !ds.podMatches(owned) {
+		t.Fatalf("expected pod owned by the configured DaemonSet to match")
+	}
+
+	ownedByStatefulSet := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "StatefulSet", Name: "foo", Controller: boolPtr(true)},
+			},
+		},
+	}
+	if ds.podMatches(ownedByStatefulSet) {
+		t.Fatalf("expected pod owned by a different kind not to match the DaemonSet shorthand")
+	}
+}
+
+func TestDaemonsetPodMatchesLabelSelector(t *testing.T) {
+	ds := Daemonset{Name: "foo", Namespace: "kube-system", LabelSelector: "app=foo"}
+	selector, err := ds.buildPodSelector()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ds.selector = selector
+
+	matching := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "foo"}}}
+	if !ds.podMatches(matching) {
+		t.Fatalf("expected pod with matching labels to match")
+	}
+
+	nonMatching := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"app": "bar"}}}
+	if ds.podMatches(nonMatching) {
+		t.Fatalf("expected pod with non-matching labels not to match")
+	}
+}
+
+func TestCalculateTaintsEscalatesExpiredTaint(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+	taintedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Taints = []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &taintedAt}}
+
+	h := &Handler{
+		config: HandlerConfig{
+			Daemonsets: []Daemonset{{Name: "foo", Namespace: "kube-system"}},
+			Disruption: DisruptionConfig{MaxTaintAge: time.Minute},
+		},
+		recorder: record.NewFakeRecorder(10),
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(false), nil
+		},
+	}
+
+	nodeCopy, changes, _, escalation, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !contains(changes.taintsAdded, expiredTaint) {
+		t.Fatalf("expected expired taint to be recorded as added, got %+v", changes)
+	}
+	found := false
+	for _, taint := range nodeCopy.Spec.Taints {
+		if taint.Key == expiredTaint && taint.Effect == corev1.TaintEffectNoExecute {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected expired NoExecute taint on node, got %+v", nodeCopy.Spec.Taints)
+	}
+
+	if escalation == nil || escalation.reason != escalationReasonExpired || escalation.daemonsetName != "kube-system/foo" {
+		t.Fatalf("expected an escalation event reported for the caller to persist, got %+v", escalation)
+	}
+}
+
+func TestCalculateTaintsRecoversCordonOnRecovery(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+	taintedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Unschedulable = true
+	node.Spec.Taints = []corev1.Taint{
+		{Key: taintName, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &taintedAt},
+		{Key: expiredTaint, Effect: corev1.TaintEffectNoExecute, TimeAdded: &taintedAt},
+	}
+
+	h := &Handler{
+		config: HandlerConfig{
+			Daemonsets: []Daemonset{{Name: "foo", Namespace: "kube-system"}},
+			Disruption: DisruptionConfig{MaxTaintAge: time.Minute, Cordon: true},
+		},
+		recorder: record.NewFakeRecorder(10),
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(true), nil
+		},
+	}
+
+	nodeCopy, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if nodeCopy.Spec.Unschedulable {
+		t.Fatalf("expected node to be uncordoned once the blocking daemonset recovered")
+	}
+	if !contains(changes.taintsRemoved, expiredTaint) {
+		t.Fatalf("expected expired taint to be recorded as removed, got %+v", changes)
+	}
+}
+
+func TestCalculateTaintsDoesNotEscalateFreshTaint(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+
+	h := &Handler{
+		config: HandlerConfig{
+			Daemonsets: []Daemonset{{Name: "foo", Namespace: "kube-system"}},
+			Disruption: DisruptionConfig{MaxTaintAge: time.Minute},
+		},
+		recorder: record.NewFakeRecorder(10),
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(false), nil
+		},
+	}
+
+	_, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if contains(changes.taintsAdded, expiredTaint) {
+		t.Fatalf("expected a freshly applied taint not to be escalated, got %+v", changes)
+	}
+	if !contains(changes.taintsAdded, taintName) {
+		t.Fatalf("expected the regular taint to still be applied, got %+v", changes)
+	}
+}
+
+func TestCalculateTaintsDoesNotEscalateFreshDrift(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+	taintedAt := metav1.NewTime(time.Now())
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Taints = []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &taintedAt}}
+
+	h := &Handler{
+		config: HandlerConfig{
+			Daemonsets: []Daemonset{{Name: "foo", Namespace: "kube-system"}},
+			Disruption: DisruptionConfig{MinDriftAge: time.Minute},
+		},
+		recorder: record.NewFakeRecorder(10),
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(false), nil
+		},
+		daemonsetGetter: func(namespace, name string) (*appsv1.DaemonSet, error) {
+			return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Generation: 2}, Status: appsv1.DaemonSetStatus{ObservedGeneration: 1}}, nil
+		},
+	}
+
+	_, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if contains(changes.taintsAdded, expiredTaint) {
+		t.Fatalf("expected a node mid-rollout to not be escalated before MinDriftAge elapses, got %+v", changes)
+	}
+}
+
+func TestCalculateTaintsEscalatesDriftedTaint(t *testing.T) {
+	taintName := "nidhogg.uswitch.com/kube-system.foo"
+	taintedAt := metav1.NewTime(time.Now().Add(-time.Hour))
+
+	node := &corev1.Node{}
+	node.Name = "node1"
+	node.Spec.Taints = []corev1.Taint{{Key: taintName, Effect: corev1.TaintEffectNoSchedule, TimeAdded: &taintedAt}}
+
+	recorder := record.NewFakeRecorder(10)
+	h := &Handler{
+		config: HandlerConfig{
+			Daemonsets: []Daemonset{{Name: "foo", Namespace: "kube-system"}},
+			Disruption: DisruptionConfig{MinDriftAge: time.Minute},
+		},
+		recorder: recorder,
+		podGetter: func(nodeName, namespace string, match func(*corev1.Pod) bool) (*corev1.Pod, error) {
+			return podWithReadiness(false), nil
+		},
+		daemonsetGetter: func(namespace, name string) (*appsv1.DaemonSet, error) {
+			return &appsv1.DaemonSet{ObjectMeta: metav1.ObjectMeta{Generation: 2}, Status: appsv1.DaemonSetStatus{ObservedGeneration: 1}}, nil
+		},
+	}
+
+	_, changes, _, _, err := h.calculateTaints(node)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !contains(changes.taintsAdded, expiredTaint) {
+		t.Fatalf("expected a drifted taint older than MinDriftAge to be escalated, got %+v", changes)
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func contains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNidhoggTaintsFiltersUnrelatedTaints(t *testing.T) {
+	taints := []corev1.Taint{
+		{Key: "nidhogg.uswitch.com/kube-system.foo", Effect: corev1.TaintEffectNoSchedule},
+		{Key: "node.kubernetes.io/unreachable", Effect: corev1.TaintEffectNoExecute},
+	}
+
+	got := nidhoggTaints(taints)
+
+	if len(got) != 1 || got[0].Key != "nidhogg.uswitch.com/kube-system.foo" {
+		t.Fatalf("expected only the nidhogg-managed taint, got %+v", got)
+	}
+}